@@ -0,0 +1,95 @@
+// Package auth provides helpers for extracting and validating API keys
+// from incoming HTTP requests.
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ErrNoAuthHeaderIncluded is returned when neither the Authorization header
+// nor the X-Api-Key fallback is present.
+var ErrNoAuthHeaderIncluded = errors.New("no authorization header included")
+
+// ErrUnsupportedAuthScheme is returned when the Authorization header is
+// present and well-formed but uses a scheme other than "ApiKey" or "Bearer".
+var ErrUnsupportedAuthScheme = errors.New("unsupported authorization scheme")
+
+// errMalformedAuthHeader is returned when the Authorization header doesn't
+// split into a scheme and a token.
+var errMalformedAuthHeader = errors.New("malformed authorization header")
+
+// authToken is a scheme/token pair parsed out of a single Authorization
+// header value.
+type authToken struct {
+	scheme string
+	token  string
+}
+
+// GetAPIKey extracts an API key from the headers of an HTTP request.
+//
+// It recognizes two Authorization schemes:
+//
+//	Authorization: ApiKey {insert apikey here}
+//	Authorization: Bearer {insert apikey here}
+//
+// If both are present (e.g. across repeated Authorization header values),
+// ApiKey takes precedence for backward compatibility. When the Authorization
+// header is absent entirely, the X-Api-Key header is used as a fallback so
+// clients that can't set Authorization (e.g. browser-based tools) can still
+// authenticate.
+func GetAPIKey(headers http.Header) (string, error) {
+	var bearer *authToken
+	var firstErr error
+
+	for _, value := range headers.Values("Authorization") {
+		if value == "" {
+			continue
+		}
+
+		tok, err := parseAuthHeader(value)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		if tok.scheme == "ApiKey" {
+			return tok.token, nil
+		}
+		if bearer == nil {
+			bearer = &tok
+		}
+	}
+
+	if bearer != nil {
+		return bearer.token, nil
+	}
+	if firstErr != nil {
+		return "", firstErr
+	}
+
+	if apiKey := headers.Get("X-Api-Key"); apiKey != "" {
+		return apiKey, nil
+	}
+
+	return "", ErrNoAuthHeaderIncluded
+}
+
+// parseAuthHeader splits a single Authorization header value into its
+// scheme and token, validating that the scheme is one GetAPIKey supports.
+func parseAuthHeader(value string) (authToken, error) {
+	splitAuth := strings.Split(value, " ")
+	if len(splitAuth) < 2 {
+		return authToken{}, errMalformedAuthHeader
+	}
+
+	switch splitAuth[0] {
+	case "ApiKey", "Bearer":
+		return authToken{scheme: splitAuth[0], token: splitAuth[1]}, nil
+	default:
+		return authToken{}, ErrUnsupportedAuthScheme
+	}
+}
@@ -47,28 +47,23 @@ func TestGetAPIKey_EmptyHeader(t *testing.T) {
 	}
 }
 
-func TestGetAPIKey_WrongFormat(t *testing.T) {
+func TestGetAPIKey_Malformed(t *testing.T) {
 	tests := []struct {
 		name       string
 		authHeader string
 	}{
-		{"BearerToken", "Bearer some-token"},
 		{"NoPrefix", "mykey123"},
-		{"WrongPrefix", "APIKEY mykey123"},
-		{"WrongPrefix2", "apikey mykey123"},
-		{"WrongPrefix3", "Api-Key mykey123"},
 		{"OnlyApiKey", "ApiKey"},
 		{"TabSeparator", "ApiKey\tmykey123"},
-		{"SpaceBefore", " ApiKey mykey123"}, // Leading space
 	}
-	
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			headers := http.Header{}
 			headers.Set("Authorization", tt.authHeader)
-			
+
 			got, err := GetAPIKey(headers)
-			
+
 			if err == nil {
 				t.Errorf("expected error for %q", tt.authHeader)
 			}
@@ -82,6 +77,124 @@ func TestGetAPIKey_WrongFormat(t *testing.T) {
 	}
 }
 
+func TestGetAPIKey_UnsupportedScheme(t *testing.T) {
+	tests := []struct {
+		name       string
+		authHeader string
+	}{
+		{"WrongPrefix", "APIKEY mykey123"},
+		{"WrongPrefix2", "apikey mykey123"},
+		{"WrongPrefix3", "Api-Key mykey123"},
+		{"WrongPrefix4", "bearer mykey123"},
+		{"SpaceBefore", " ApiKey mykey123"}, // Leading space shifts the scheme token
+		{"Basic", "Basic dXNlcjpwYXNz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := http.Header{}
+			headers.Set("Authorization", tt.authHeader)
+
+			got, err := GetAPIKey(headers)
+
+			if err != ErrUnsupportedAuthScheme {
+				t.Errorf("expected ErrUnsupportedAuthScheme for %q, got: %v", tt.authHeader, err)
+			}
+			if got != "" {
+				t.Errorf("expected empty string for %q, got %q", tt.authHeader, got)
+			}
+		})
+	}
+}
+
+func TestGetAPIKey_BearerScheme(t *testing.T) {
+	headers := http.Header{}
+	expected := "test-bearer-token"
+	headers.Set("Authorization", "Bearer "+expected)
+
+	got, err := GetAPIKey(headers)
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestGetAPIKey_ApiKeyPreferredOverBearer(t *testing.T) {
+	headers := http.Header{}
+	headers.Add("Authorization", "Bearer bearer-token")
+	headers.Add("Authorization", "ApiKey apikey-token")
+
+	got, err := GetAPIKey(headers)
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if got != "apikey-token" {
+		t.Errorf("expected ApiKey value to take precedence, got %q", got)
+	}
+}
+
+func TestGetAPIKey_XAPIKeyFallback(t *testing.T) {
+	headers := http.Header{}
+	expected := "fallback-key"
+	headers.Set("X-Api-Key", expected)
+
+	got, err := GetAPIKey(headers)
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestGetAPIKey_AuthorizationPreferredOverXAPIKey(t *testing.T) {
+	headers := http.Header{}
+	headers.Set("Authorization", "ApiKey auth-header-key")
+	headers.Set("X-Api-Key", "x-api-key-header-key")
+
+	got, err := GetAPIKey(headers)
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if got != "auth-header-key" {
+		t.Errorf("expected the Authorization header value to win, got %q", got)
+	}
+}
+
+func TestGetAPIKey_XAPIKeyHeaderCaseInsensitive(t *testing.T) {
+	headers := http.Header{}
+	expected := "case-insensitive-key"
+	headers.Set("x-api-key", expected)
+
+	got, err := GetAPIKey(headers)
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestGetAPIKey_NoAuthAtAll(t *testing.T) {
+	headers := http.Header{}
+
+	got, err := GetAPIKey(headers)
+
+	if err != ErrNoAuthHeaderIncluded {
+		t.Errorf("expected ErrNoAuthHeaderIncluded, got: %v", err)
+	}
+	if got != "" {
+		t.Errorf("expected empty string, got %q", got)
+	}
+}
+
 func TestGetAPIKey_ValidVariations(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -149,8 +262,8 @@ func TestGetAPIKey_CaseSensitive(t *testing.T) {
 	
 	headers.Set("Authorization", "apikey wrong-key")
 	got, err = GetAPIKey(headers)
-	if err == nil || err.Error() != "malformed authorization header" {
-		t.Errorf("lowercase 'apikey' should fail: err=%v", err)
+	if err != ErrUnsupportedAuthScheme {
+		t.Errorf("lowercase 'apikey' should be an unsupported scheme: err=%v", err)
 	}
 }
 
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+)
+
+// ErrKeyNotFound is returned by a KeyStore when the presented API key does
+// not match any key it holds.
+var ErrKeyNotFound = errors.New("api key not found")
+
+// KeyStore authenticates API keys and resolves them to the Principal they
+// belong to.
+type KeyStore interface {
+	Validate(ctx context.Context, key string) (Principal, error)
+}
+
+// keyHash is the SHA-256 digest of an API key. KeyStores index by this
+// instead of the plaintext key so a compromised process dump never exposes
+// a usable key.
+type keyHash [sha256.Size]byte
+
+func hashKey(key string) keyHash {
+	return sha256.Sum256([]byte(key))
+}
+
+// StaticKeyStore validates API keys against a fixed set of SHA-256 hashes.
+// Comparisons use crypto/subtle.ConstantTimeCompare so the time taken to
+// reject a key doesn't leak how much of it was correct.
+type StaticKeyStore struct {
+	hashes map[keyHash]Principal
+}
+
+// NewStaticKeyStore builds a StaticKeyStore from a map of plaintext API keys
+// to the Principal each one authenticates as. Keys are hashed immediately;
+// the plaintext is not retained.
+func NewStaticKeyStore(keys map[string]Principal) *StaticKeyStore {
+	hashes := make(map[keyHash]Principal, len(keys))
+	for key, principal := range keys {
+		hashes[hashKey(key)] = principal
+	}
+	return &StaticKeyStore{hashes: hashes}
+}
+
+// Validate implements KeyStore. It compares digest against every stored
+// hash and keeps scanning after a match, rather than returning as soon as
+// one is found, so the time taken doesn't vary with where in the set (or
+// whether) the key matches.
+func (s *StaticKeyStore) Validate(_ context.Context, key string) (Principal, error) {
+	digest := hashKey(key)
+
+	var match Principal
+	found := 0
+	for stored, principal := range s.hashes {
+		if subtle.ConstantTimeCompare(stored[:], digest[:]) == 1 {
+			match = principal
+			found = 1
+		}
+	}
+	if found == 1 {
+		return match, nil
+	}
+	return Principal{}, ErrKeyNotFound
+}
+
+// RotatingKeyStore validates API keys against a "current" key set, falling
+// back to a "previous" set if the current one doesn't match. This supports
+// zero-downtime key rotation: once the current set is updated, clients still
+// presenting the previous generation's key keep authenticating until they
+// pick up the new one.
+type RotatingKeyStore struct {
+	current  *StaticKeyStore
+	previous *StaticKeyStore
+}
+
+// NewRotatingKeyStore builds a RotatingKeyStore from the current and
+// previous generation of API keys. previous may be nil or empty before the
+// first rotation has happened.
+func NewRotatingKeyStore(current, previous map[string]Principal) *RotatingKeyStore {
+	return &RotatingKeyStore{
+		current:  NewStaticKeyStore(current),
+		previous: NewStaticKeyStore(previous),
+	}
+}
+
+// Validate implements KeyStore, preferring the current key set over the
+// previous one.
+func (r *RotatingKeyStore) Validate(ctx context.Context, key string) (Principal, error) {
+	if principal, err := r.current.Validate(ctx, key); err == nil {
+		return principal, nil
+	}
+	return r.previous.Validate(ctx, key)
+}
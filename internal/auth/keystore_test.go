@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStaticKeyStore_Validate(t *testing.T) {
+	store := NewStaticKeyStore(map[string]Principal{
+		"key-alice": {ID: "alice"},
+		"key-bob":   {ID: "bob"},
+	})
+
+	t.Run("KnownKey", func(t *testing.T) {
+		principal, err := store.Validate(context.Background(), "key-alice")
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if principal.ID != "alice" {
+			t.Errorf("expected alice, got %q", principal.ID)
+		}
+	})
+
+	t.Run("UnknownKey", func(t *testing.T) {
+		_, err := store.Validate(context.Background(), "key-carol")
+		if err != ErrKeyNotFound {
+			t.Errorf("expected ErrKeyNotFound, got: %v", err)
+		}
+	})
+
+	t.Run("EmptyKey", func(t *testing.T) {
+		_, err := store.Validate(context.Background(), "")
+		if err != ErrKeyNotFound {
+			t.Errorf("expected ErrKeyNotFound, got: %v", err)
+		}
+	})
+}
+
+func TestRotatingKeyStore_Validate(t *testing.T) {
+	current := map[string]Principal{"key-v2": {ID: "alice"}}
+	previous := map[string]Principal{"key-v1": {ID: "alice"}}
+	store := NewRotatingKeyStore(current, previous)
+
+	t.Run("CurrentKey", func(t *testing.T) {
+		principal, err := store.Validate(context.Background(), "key-v2")
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if principal.ID != "alice" {
+			t.Errorf("expected alice, got %q", principal.ID)
+		}
+	})
+
+	t.Run("RotatedKeyStillValid", func(t *testing.T) {
+		principal, err := store.Validate(context.Background(), "key-v1")
+		if err != nil {
+			t.Fatalf("expected the previous-generation key to still validate, got: %v", err)
+		}
+		if principal.ID != "alice" {
+			t.Errorf("expected alice, got %q", principal.ID)
+		}
+	})
+
+	t.Run("ExpiredKey", func(t *testing.T) {
+		// A key from before the last rotation, present in neither set,
+		// must be rejected.
+		_, err := store.Validate(context.Background(), "key-v0")
+		if err != ErrKeyNotFound {
+			t.Errorf("expected ErrKeyNotFound for an expired key, got: %v", err)
+		}
+	})
+
+	t.Run("UnknownKey", func(t *testing.T) {
+		_, err := store.Validate(context.Background(), "key-mallory")
+		if err != ErrKeyNotFound {
+			t.Errorf("expected ErrKeyNotFound, got: %v", err)
+		}
+	})
+}
+
+func TestRotatingKeyStore_NilPrevious(t *testing.T) {
+	store := NewRotatingKeyStore(map[string]Principal{"key-v1": {ID: "alice"}}, nil)
+
+	if _, err := store.Validate(context.Background(), "key-v0"); err != ErrKeyNotFound {
+		t.Errorf("expected ErrKeyNotFound with no previous key set, got: %v", err)
+	}
+}
+
+func TestMiddleware_WithStaticKeyStore(t *testing.T) {
+	store := NewStaticKeyStore(map[string]Principal{"good-key": {ID: "user-1"}})
+
+	handler := Middleware(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := FromContext(r.Context())
+		if !ok {
+			t.Error("expected principal in request context")
+		}
+		w.Write([]byte(principal.ID))
+	}))
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Authorization", "ApiKey good-key")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "user-1" {
+		t.Errorf("expected user-1, got %q", body)
+	}
+}
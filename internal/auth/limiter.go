@@ -0,0 +1,371 @@
+package auth
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// Clock abstracts time.Now so Limiter's lockout math and sweep can be
+// exercised deterministically in tests.
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// AuthAttempt describes a single authentication attempt, successful or
+// not, for consumption by an EventLogger.
+type AuthAttempt struct {
+	Success bool
+	// Username is the authenticated Principal's ID on a successful
+	// attempt. It's empty on failure, since the identity behind a rejected
+	// key is, by definition, unconfirmed.
+	Username  string
+	KeyPrefix string
+	Address   string
+	Time      time.Time
+}
+
+// EventLogger receives AuthAttempt events so operators can audit
+// brute-force activity.
+type EventLogger interface {
+	LogAuthAttempt(AuthAttempt)
+}
+
+// NoopEventLogger discards every event. It's the default EventLogger for a
+// Limiter built without one.
+type NoopEventLogger struct{}
+
+// LogAuthAttempt implements EventLogger.
+func (NoopEventLogger) LogAuthAttempt(AuthAttempt) {}
+
+// LimiterConfig configures a Limiter's thresholds.
+type LimiterConfig struct {
+	// MaxAttempts is the number of failed attempts allowed within Window
+	// before a source is locked out.
+	MaxAttempts int
+	// Window is the sliding period over which failures are counted.
+	Window time.Duration
+	// BaseLockout is how long a source is locked out the first time it
+	// trips the limiter. Each subsequent lockout of the same source doubles
+	// it, up to MaxLockout. The lockout count is not reset by the passage of
+	// time; it is dropped only when Sweep evicts the source's bucket.
+	BaseLockout time.Duration
+	// MaxLockout caps the exponential backoff. Zero means uncapped.
+	MaxLockout time.Duration
+	// BucketCapacity is the number of tokens a source's bucket holds.
+	// Allow consumes one token per attempt (IP and, once a key is
+	// presented, key prefix both have their own bucket); once a bucket is
+	// empty, further attempts are throttled with 429 until it refills.
+	// Zero disables token-bucket throttling, leaving only the exponential
+	// lockout above.
+	BucketCapacity int
+	// RefillInterval is how often a bucket regains a single token, up to
+	// BucketCapacity. Ignored when BucketCapacity is zero.
+	RefillInterval time.Duration
+	// TrustedProxies lists CIDRs of reverse proxies allowed to set
+	// X-Forwarded-For. A request whose immediate peer isn't in this list
+	// has its X-Forwarded-For header ignored, so it can't be used to spoof
+	// a different source address.
+	TrustedProxies []string
+	// SweepInterval is how often stale buckets are evicted by a background
+	// goroutine. Zero disables the goroutine; Sweep can still be called
+	// directly, which is how tests drive eviction deterministically.
+	SweepInterval time.Duration
+}
+
+// bucket holds the brute-force bookkeeping for one source, either an IP
+// address or a presented key prefix.
+type bucket struct {
+	failures    []time.Time
+	lockouts    int
+	lockedUntil time.Time
+
+	// tokens and lastRefill back the token-bucket throttle. tokens is
+	// meaningless until lastRefill is set, which takeToken does lazily on
+	// first use so a freshly-created bucket starts full.
+	tokens     int
+	lastRefill time.Time
+}
+
+// Limiter tracks failed authentication attempts per source IP and per
+// presented API key prefix. It throttles bursts with a token bucket
+// (LimiterConfig.BucketCapacity/RefillInterval) and locks a source out
+// with exponential backoff once it reaches LimiterConfig.MaxAttempts
+// failures within LimiterConfig.Window.
+type Limiter struct {
+	cfg            LimiterConfig
+	clock          Clock
+	logger         EventLogger
+	trustedProxies []*net.IPNet
+
+	mu    sync.Mutex
+	byIP  map[string]*bucket
+	byKey map[string]*bucket
+
+	stop chan struct{}
+}
+
+// NewLimiter builds a Limiter. If clock is nil, time.Now is used. If
+// logger is nil, events are discarded. When cfg.SweepInterval is positive,
+// a background goroutine periodically evicts inactive buckets; call Close
+// to stop it.
+func NewLimiter(cfg LimiterConfig, logger EventLogger, clock Clock) *Limiter {
+	if clock == nil {
+		clock = realClock{}
+	}
+	if logger == nil {
+		logger = NoopEventLogger{}
+	}
+
+	var proxies []*net.IPNet
+	for _, cidr := range cfg.TrustedProxies {
+		if _, network, err := net.ParseCIDR(cidr); err == nil {
+			proxies = append(proxies, network)
+		}
+	}
+
+	l := &Limiter{
+		cfg:            cfg,
+		clock:          clock,
+		logger:         logger,
+		trustedProxies: proxies,
+		byIP:           make(map[string]*bucket),
+		byKey:          make(map[string]*bucket),
+		stop:           make(chan struct{}),
+	}
+
+	if cfg.SweepInterval > 0 {
+		go l.sweepLoop()
+	}
+
+	return l
+}
+
+// Close stops the background sweep goroutine started by NewLimiter, if
+// any. It is a no-op if SweepInterval was zero or Close was already called.
+func (l *Limiter) Close() {
+	select {
+	case <-l.stop:
+	default:
+		close(l.stop)
+	}
+}
+
+func (l *Limiter) sweepLoop() {
+	ticker := time.NewTicker(l.cfg.SweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.Sweep()
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+// Sweep evicts buckets that are both unlocked and hold no failures within
+// the current window. It runs automatically when LimiterConfig.SweepInterval
+// is set, and can be called directly to drive eviction deterministically
+// against an injected Clock in tests.
+func (l *Limiter) Sweep() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	sweepMap := func(m map[string]*bucket) {
+		for key, b := range m {
+			if now.After(b.lockedUntil) && len(l.recentFailures(b, now)) == 0 {
+				delete(m, key)
+			}
+		}
+	}
+	sweepMap(l.byIP)
+	sweepMap(l.byKey)
+}
+
+// recentFailures trims b.failures to those within the current window and
+// returns the result. Must be called with l.mu held.
+func (l *Limiter) recentFailures(b *bucket, now time.Time) []time.Time {
+	cutoff := now.Add(-l.cfg.Window)
+	kept := b.failures[:0]
+	for _, t := range b.failures {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	b.failures = kept
+	return b.failures
+}
+
+// Allow reports whether a request from ip presenting a key with the given
+// prefix should proceed, or be rejected with a 429 and the returned
+// retryAfter. keyPrefix may be empty if no key was presented yet.
+//
+// Allow is a convenience wrapper around AllowIP and, once a key is known,
+// AllowKey; callers that check a request's IP and key prefix separately
+// (as Middleware does, since the key isn't known until GetAPIKey has run)
+// should call those directly instead, so each bucket is only debited once
+// per request.
+func (l *Limiter) Allow(ip, keyPrefix string) (allowed bool, retryAfter time.Duration) {
+	if allowed, retryAfter := l.AllowIP(ip); !allowed {
+		return false, retryAfter
+	}
+	if keyPrefix != "" {
+		if allowed, retryAfter := l.AllowKey(keyPrefix); !allowed {
+			return false, retryAfter
+		}
+	}
+	return true, 0
+}
+
+// AllowIP reports whether a request from ip should proceed, or be rejected
+// with a 429 and the returned retryAfter. A source already under an
+// exponential lockout is rejected outright; otherwise, if
+// LimiterConfig.BucketCapacity is set, AllowIP consumes one token from the
+// IP's bucket, rejecting the request until it refills if none remain.
+func (l *Limiter) AllowIP(ip string) (allowed bool, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+
+	if b, ok := l.byIP[ip]; ok && now.Before(b.lockedUntil) {
+		return false, b.lockedUntil.Sub(now)
+	}
+	if l.cfg.BucketCapacity > 0 {
+		if ok, retryAfter := l.takeToken(l.bucketFor(l.byIP, ip), now); !ok {
+			return false, retryAfter
+		}
+	}
+	return true, 0
+}
+
+// AllowKey reports whether a request presenting a key with the given
+// prefix should proceed, or be rejected with a 429 and the returned
+// retryAfter. It applies the same lockout and token-bucket rules as
+// AllowIP, against the key prefix's own bucket.
+func (l *Limiter) AllowKey(keyPrefix string) (allowed bool, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+
+	if b, ok := l.byKey[keyPrefix]; ok && now.Before(b.lockedUntil) {
+		return false, b.lockedUntil.Sub(now)
+	}
+	if l.cfg.BucketCapacity > 0 {
+		if ok, retryAfter := l.takeToken(l.bucketFor(l.byKey, keyPrefix), now); !ok {
+			return false, retryAfter
+		}
+	}
+	return true, 0
+}
+
+// takeToken refills b based on elapsed time and consumes one token from it,
+// reporting whether a token was available and, if not, how long until the
+// next one refills. Must be called with l.mu held.
+func (l *Limiter) takeToken(b *bucket, now time.Time) (allowed bool, retryAfter time.Duration) {
+	if b.lastRefill.IsZero() {
+		b.tokens = l.cfg.BucketCapacity
+		b.lastRefill = now
+	} else if l.cfg.RefillInterval > 0 {
+		if elapsed := now.Sub(b.lastRefill); elapsed > 0 {
+			if refilled := int(elapsed / l.cfg.RefillInterval); refilled > 0 {
+				b.tokens += refilled
+				if b.tokens > l.cfg.BucketCapacity {
+					b.tokens = l.cfg.BucketCapacity
+				}
+				b.lastRefill = b.lastRefill.Add(time.Duration(refilled) * l.cfg.RefillInterval)
+			}
+		}
+	}
+
+	if b.tokens <= 0 {
+		retryAfter = l.cfg.RefillInterval - now.Sub(b.lastRefill)
+		if retryAfter < 0 {
+			retryAfter = l.cfg.RefillInterval
+		}
+		return false, retryAfter
+	}
+
+	b.tokens--
+	return true, 0
+}
+
+// RecordFailure registers a failed attempt from ip presenting a key with
+// the given prefix, locking the source out once it reaches
+// LimiterConfig.MaxAttempts failures within LimiterConfig.Window. Each
+// lockout of the same source doubles the previous one, up to MaxLockout.
+func (l *Limiter) RecordFailure(ip, keyPrefix string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	l.recordFailureLocked(l.bucketFor(l.byIP, ip), now)
+	if keyPrefix != "" {
+		l.recordFailureLocked(l.bucketFor(l.byKey, keyPrefix), now)
+	}
+}
+
+// RecordSuccess clears the accumulated failure history for ip and
+// keyPrefix. A successful authentication resets the brute-force counters
+// but does not lift a lockout already in effect.
+func (l *Limiter) RecordSuccess(ip, keyPrefix string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if b, ok := l.byIP[ip]; ok {
+		b.failures = nil
+	}
+	if keyPrefix != "" {
+		if b, ok := l.byKey[keyPrefix]; ok {
+			b.failures = nil
+		}
+	}
+}
+
+func (l *Limiter) bucketFor(m map[string]*bucket, key string) *bucket {
+	b, ok := m[key]
+	if !ok {
+		b = &bucket{}
+		m[key] = b
+	}
+	return b
+}
+
+func (l *Limiter) recordFailureLocked(b *bucket, now time.Time) {
+	b.failures = append(l.recentFailures(b, now), now)
+	if len(b.failures) < l.cfg.MaxAttempts {
+		return
+	}
+
+	b.failures = nil
+	shift := b.lockouts
+	if shift > 32 {
+		shift = 32
+	}
+	lockout := l.cfg.BaseLockout << uint(shift)
+	if l.cfg.MaxLockout > 0 && lockout > l.cfg.MaxLockout {
+		lockout = l.cfg.MaxLockout
+	}
+	b.lockedUntil = now.Add(lockout)
+	b.lockouts++
+}
+
+// logAttempt reports an AuthAttempt to the configured EventLogger. username
+// should be the authenticated Principal's ID on success, and empty on
+// failure.
+func (l *Limiter) logAttempt(success bool, username, keyPrefix, address string) {
+	l.logger.LogAuthAttempt(AuthAttempt{
+		Success:   success,
+		Username:  username,
+		KeyPrefix: keyPrefix,
+		Address:   address,
+		Time:      l.clock.Now(),
+	})
+}
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// keyPrefixLen is how much of a presented API key is kept for per-key
+// bucketing. It's long enough to distinguish keys; a longer key is cut to
+// this length, while a key of keyPrefixLen bytes or fewer is kept whole.
+const keyPrefixLen = 8
+
+func keyPrefix(key string) string {
+	if len(key) <= keyPrefixLen {
+		return key
+	}
+	return key[:keyPrefixLen]
+}
+
+// clientAddr resolves the address a request should be rate-limited under.
+// It trusts X-Forwarded-For only when the immediate peer (r.RemoteAddr) is
+// in l.trustedProxies; otherwise a spoofed header could be used to evade
+// the limiter entirely. When trusted, it walks the forwarded chain from
+// the right and returns the first address not itself a trusted proxy.
+func (l *Limiter) clientAddr(r *http.Request) string {
+	peer := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(peer); err == nil {
+		peer = host
+	}
+
+	if !l.isTrustedProxy(peer) {
+		return peer
+	}
+
+	forwarded := r.Header.Get("X-Forwarded-For")
+	if forwarded == "" {
+		return peer
+	}
+
+	hops := strings.Split(forwarded, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		candidate := strings.TrimSpace(hops[i])
+		if !l.isTrustedProxy(candidate) {
+			return candidate
+		}
+	}
+	return strings.TrimSpace(hops[0])
+}
+
+func (l *Limiter) isTrustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range l.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware wraps an http.Handler with brute-force protection on top of
+// store-backed API key authentication. It behaves like Middleware, except
+// that it checks the request's source against l in two steps — the IP via
+// AllowIP, then the key prefix via AllowKey once the key has been parsed —
+// rejecting with 429 and a Retry-After header when either is locked out or
+// throttled. Every outcome, including a 429, is reported to l's
+// EventLogger so it remains auditable. A missing, malformed or rejected key
+// is recorded into l with RecordFailure, so repeated failures trip the
+// lockout, and an accepted key with RecordSuccess; a 429 is not recorded.
+func (l *Limiter) Middleware(store KeyStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			addr := l.clientAddr(r)
+
+			if allowed, retryAfter := l.AllowIP(addr); !allowed {
+				l.logAttempt(false, "", "", addr)
+				respondRateLimited(w, retryAfter)
+				return
+			}
+
+			key, err := GetAPIKey(r.Header)
+			if err != nil {
+				l.RecordFailure(addr, "")
+				l.logAttempt(false, "", "", addr)
+				respondAuthError(w, http.StatusUnauthorized, err.Error())
+				return
+			}
+
+			prefix := keyPrefix(key)
+			if allowed, retryAfter := l.AllowKey(prefix); !allowed {
+				l.logAttempt(false, "", prefix, addr)
+				respondRateLimited(w, retryAfter)
+				return
+			}
+
+			principal, err := store.Validate(r.Context(), key)
+			if err != nil {
+				l.RecordFailure(addr, prefix)
+				l.logAttempt(false, "", prefix, addr)
+				respondAuthError(w, http.StatusForbidden, err.Error())
+				return
+			}
+
+			l.RecordSuccess(addr, prefix)
+			l.logAttempt(true, principal.ID, prefix, addr)
+
+			ctx := context.WithValue(r.Context(), principalContextKey, principal)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func respondRateLimited(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int(retryAfter.Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	respondAuthError(w, http.StatusTooManyRequests, "too many failed authentication attempts")
+}
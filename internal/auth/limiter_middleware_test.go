@@ -0,0 +1,214 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func testRateLimitedServer(t *testing.T, limiter *Limiter) *httptest.Server {
+	t.Helper()
+
+	store := NewStaticKeyStore(map[string]Principal{"good-key": {ID: "user-1"}})
+	handler := limiter.Middleware(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func doAuthRequest(t *testing.T, serverURL, key string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, serverURL, nil)
+	if key != "" {
+		req.Header.Set("Authorization", "ApiKey "+key)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	return resp
+}
+
+func TestLimiterMiddleware_LocksOutAfterRepeatedFailures(t *testing.T) {
+	clock := newFakeClock(time.Unix(0, 0))
+	limiter := NewLimiter(LimiterConfig{
+		MaxAttempts: 2,
+		Window:      time.Minute,
+		BaseLockout: 30 * time.Second,
+		MaxLockout:  time.Hour,
+	}, nil, clock)
+
+	server := testRateLimitedServer(t, limiter)
+
+	for i := 0; i < 2; i++ {
+		resp := doAuthRequest(t, server.URL, "bad-key")
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("attempt %d: expected 403, got %d", i, resp.StatusCode)
+		}
+	}
+
+	resp := doAuthRequest(t, server.URL, "bad-key")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 after tripping the limiter, got %d", resp.StatusCode)
+	}
+	retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || retryAfter <= 0 {
+		t.Errorf("expected a positive Retry-After header, got %q", resp.Header.Get("Retry-After"))
+	}
+}
+
+func TestLimiterMiddleware_SuccessPassesThrough(t *testing.T) {
+	clock := newFakeClock(time.Unix(0, 0))
+	limiter := NewLimiter(testLimiterConfig(), nil, clock)
+
+	server := testRateLimitedServer(t, limiter)
+
+	resp := doAuthRequest(t, server.URL, "good-key")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestLimiterMiddleware_LogsUsernameOnSuccess(t *testing.T) {
+	logger := &recordingLogger{}
+	clock := newFakeClock(time.Unix(0, 0))
+	limiter := NewLimiter(testLimiterConfig(), logger, clock)
+
+	server := testRateLimitedServer(t, limiter)
+
+	resp := doAuthRequest(t, server.URL, "good-key")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	if len(logger.events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(logger.events))
+	}
+	if logger.events[0].Username != "user-1" {
+		t.Errorf("expected username %q, got %q", "user-1", logger.events[0].Username)
+	}
+}
+
+func TestLimiterMiddleware_TokenBucketChargesOneTokenPerRequest(t *testing.T) {
+	clock := newFakeClock(time.Unix(0, 0))
+	limiter := NewLimiter(LimiterConfig{
+		MaxAttempts:    100,
+		Window:         time.Minute,
+		BaseLockout:    time.Minute,
+		BucketCapacity: 3,
+		RefillInterval: time.Hour,
+	}, nil, clock)
+
+	server := testRateLimitedServer(t, limiter)
+
+	for i := 0; i < 3; i++ {
+		resp := doAuthRequest(t, server.URL, "good-key")
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("request %d: expected 200 within the bucket's capacity, got %d", i, resp.StatusCode)
+		}
+	}
+
+	resp := doAuthRequest(t, server.URL, "good-key")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 once the bucket's capacity is spent, got %d", resp.StatusCode)
+	}
+}
+
+func TestLimiterMiddleware_LogsFailureOnRateLimit(t *testing.T) {
+	logger := &recordingLogger{}
+	clock := newFakeClock(time.Unix(0, 0))
+	limiter := NewLimiter(LimiterConfig{
+		MaxAttempts:    100,
+		Window:         time.Minute,
+		BaseLockout:    time.Minute,
+		BucketCapacity: 1,
+		RefillInterval: time.Hour,
+	}, logger, clock)
+
+	server := testRateLimitedServer(t, limiter)
+
+	resp := doAuthRequest(t, server.URL, "good-key")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the first request to pass, got %d", resp.StatusCode)
+	}
+
+	resp = doAuthRequest(t, server.URL, "good-key")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 once the bucket's capacity is spent, got %d", resp.StatusCode)
+	}
+
+	if len(logger.events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(logger.events))
+	}
+	last := logger.events[len(logger.events)-1]
+	if last.Success {
+		t.Error("expected the rate-limited attempt to be logged as a failure")
+	}
+}
+
+func TestLimiterMiddleware_TrustedProxyXFF(t *testing.T) {
+	clock := newFakeClock(time.Unix(0, 0))
+	limiter := NewLimiter(LimiterConfig{
+		MaxAttempts:    1,
+		Window:         time.Minute,
+		BaseLockout:    time.Minute,
+		MaxLockout:     time.Hour,
+		TrustedProxies: []string{"127.0.0.1/32", "::1/128"},
+	}, nil, clock)
+
+	store := NewStaticKeyStore(map[string]Principal{"good-key": {ID: "user-1"}})
+	handler := limiter.Middleware(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	request := func(xff, key string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		if key != "" {
+			req.Header.Set("Authorization", "ApiKey "+key)
+		}
+		if xff != "" {
+			req.Header.Set("X-Forwarded-For", xff)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		return resp
+	}
+
+	// Trip the limiter for client 9.9.9.9 via a trusted-proxy-forwarded request.
+	resp := request("9.9.9.9", "bad-key")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", resp.StatusCode)
+	}
+
+	// The same forwarded client is now locked out...
+	resp = request("9.9.9.9", "good-key")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 for the locked-out forwarded client, got %d", resp.StatusCode)
+	}
+
+	// ...but a different forwarded client behind the same trusted proxy is not.
+	resp = request("1.1.1.1", "good-key")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 for an unrelated forwarded client, got %d", resp.StatusCode)
+	}
+}
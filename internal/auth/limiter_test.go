@@ -0,0 +1,285 @@
+package auth
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually-advanced Clock so lockout and sweep behavior can
+// be tested without relying on real time passing.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock(start time.Time) *fakeClock {
+	return &fakeClock{now: start}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+type recordingLogger struct {
+	mu     sync.Mutex
+	events []AuthAttempt
+}
+
+func (r *recordingLogger) LogAuthAttempt(e AuthAttempt) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func testLimiterConfig() LimiterConfig {
+	return LimiterConfig{
+		MaxAttempts: 3,
+		Window:      time.Minute,
+		BaseLockout: time.Second,
+		MaxLockout:  time.Hour,
+	}
+}
+
+func TestLimiter_AllowsUntilThreshold(t *testing.T) {
+	clock := newFakeClock(time.Unix(0, 0))
+	limiter := NewLimiter(testLimiterConfig(), nil, clock)
+
+	for i := 0; i < 2; i++ {
+		if allowed, _ := limiter.Allow("1.2.3.4", ""); !allowed {
+			t.Fatalf("expected request %d to be allowed", i)
+		}
+		limiter.RecordFailure("1.2.3.4", "")
+	}
+
+	if allowed, _ := limiter.Allow("1.2.3.4", ""); !allowed {
+		t.Fatal("expected one more attempt before lockout")
+	}
+	limiter.RecordFailure("1.2.3.4", "")
+
+	allowed, retryAfter := limiter.Allow("1.2.3.4", "")
+	if allowed {
+		t.Fatal("expected source to be locked out after MaxAttempts failures")
+	}
+	if retryAfter <= 0 {
+		t.Errorf("expected a positive retry-after, got %v", retryAfter)
+	}
+}
+
+func TestLimiter_LockoutExpires(t *testing.T) {
+	clock := newFakeClock(time.Unix(0, 0))
+	limiter := NewLimiter(testLimiterConfig(), nil, clock)
+
+	for i := 0; i < 3; i++ {
+		limiter.RecordFailure("1.2.3.4", "")
+	}
+	if allowed, _ := limiter.Allow("1.2.3.4", ""); allowed {
+		t.Fatal("expected lockout immediately after tripping")
+	}
+
+	clock.Advance(time.Second + time.Millisecond)
+
+	if allowed, _ := limiter.Allow("1.2.3.4", ""); !allowed {
+		t.Fatal("expected lockout to have expired")
+	}
+}
+
+func TestLimiter_ExponentialBackoff(t *testing.T) {
+	clock := newFakeClock(time.Unix(0, 0))
+	limiter := NewLimiter(testLimiterConfig(), nil, clock)
+
+	tripLockout := func() time.Duration {
+		for i := 0; i < 3; i++ {
+			limiter.RecordFailure("1.2.3.4", "")
+		}
+		_, retryAfter := limiter.Allow("1.2.3.4", "")
+		return retryAfter
+	}
+
+	first := tripLockout()
+	clock.Advance(first + time.Millisecond)
+
+	second := tripLockout()
+	if second <= first {
+		t.Errorf("expected second lockout (%v) to exceed the first (%v)", second, first)
+	}
+}
+
+func TestLimiter_MaxLockoutCap(t *testing.T) {
+	cfg := testLimiterConfig()
+	cfg.MaxLockout = 5 * time.Second
+	clock := newFakeClock(time.Unix(0, 0))
+	limiter := NewLimiter(cfg, nil, clock)
+
+	var last time.Duration
+	for round := 0; round < 6; round++ {
+		for i := 0; i < cfg.MaxAttempts; i++ {
+			limiter.RecordFailure("1.2.3.4", "")
+		}
+		_, retryAfter := limiter.Allow("1.2.3.4", "")
+		last = retryAfter
+		clock.Advance(retryAfter + time.Millisecond)
+	}
+
+	if last > cfg.MaxLockout {
+		t.Errorf("expected lockout to be capped at %v, got %v", cfg.MaxLockout, last)
+	}
+}
+
+func TestLimiter_SuccessResetsFailureCount(t *testing.T) {
+	clock := newFakeClock(time.Unix(0, 0))
+	limiter := NewLimiter(testLimiterConfig(), nil, clock)
+
+	limiter.RecordFailure("1.2.3.4", "")
+	limiter.RecordFailure("1.2.3.4", "")
+	limiter.RecordSuccess("1.2.3.4", "")
+	limiter.RecordFailure("1.2.3.4", "")
+
+	if allowed, _ := limiter.Allow("1.2.3.4", ""); !allowed {
+		t.Fatal("expected success to reset the failure count, avoiding lockout")
+	}
+}
+
+func TestLimiter_KeyPrefixLockoutAppliesAcrossIPs(t *testing.T) {
+	clock := newFakeClock(time.Unix(0, 0))
+	limiter := NewLimiter(testLimiterConfig(), nil, clock)
+
+	for i := 0; i < 3; i++ {
+		limiter.RecordFailure("1.2.3.4", "badkey12")
+	}
+
+	if allowed, _ := limiter.Allow("5.6.7.8", "badkey12"); allowed {
+		t.Error("expected the locked-out key prefix to block a different IP presenting the same key")
+	}
+	if allowed, _ := limiter.Allow("5.6.7.8", "otherkey"); !allowed {
+		t.Error("expected an unrelated key prefix from a fresh IP to still be allowed")
+	}
+}
+
+func TestLimiter_EventLogger(t *testing.T) {
+	logger := &recordingLogger{}
+	clock := newFakeClock(time.Unix(0, 0))
+	limiter := NewLimiter(testLimiterConfig(), logger, clock)
+
+	limiter.logAttempt(false, "", "badkey12", "1.2.3.4")
+	limiter.logAttempt(true, "alice", "goodkey1", "1.2.3.4")
+
+	if len(logger.events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(logger.events))
+	}
+	if logger.events[0].Success {
+		t.Error("expected first event to be a failure")
+	}
+	if logger.events[0].Username != "" {
+		t.Errorf("expected no username on a failed attempt, got %q", logger.events[0].Username)
+	}
+	if !logger.events[1].Success {
+		t.Error("expected second event to be a success")
+	}
+	if logger.events[1].Username != "alice" {
+		t.Errorf("expected username %q, got %q", "alice", logger.events[1].Username)
+	}
+}
+
+func TestLimiter_TokenBucketThrottlesBursts(t *testing.T) {
+	cfg := testLimiterConfig()
+	cfg.BucketCapacity = 2
+	cfg.RefillInterval = time.Second
+	clock := newFakeClock(time.Unix(0, 0))
+	limiter := NewLimiter(cfg, nil, clock)
+
+	for i := 0; i < 2; i++ {
+		if allowed, _ := limiter.Allow("1.2.3.4", ""); !allowed {
+			t.Fatalf("expected burst request %d to be allowed", i)
+		}
+	}
+
+	allowed, retryAfter := limiter.Allow("1.2.3.4", "")
+	if allowed {
+		t.Fatal("expected the bucket to be empty after consuming its capacity")
+	}
+	if retryAfter <= 0 {
+		t.Errorf("expected a positive retry-after, got %v", retryAfter)
+	}
+}
+
+func TestLimiter_TokenBucketRefills(t *testing.T) {
+	cfg := testLimiterConfig()
+	cfg.BucketCapacity = 1
+	cfg.RefillInterval = time.Second
+	clock := newFakeClock(time.Unix(0, 0))
+	limiter := NewLimiter(cfg, nil, clock)
+
+	if allowed, _ := limiter.Allow("1.2.3.4", ""); !allowed {
+		t.Fatal("expected the first request to consume the bucket's only token")
+	}
+	if allowed, _ := limiter.Allow("1.2.3.4", ""); allowed {
+		t.Fatal("expected the bucket to be empty")
+	}
+
+	clock.Advance(time.Second)
+
+	if allowed, _ := limiter.Allow("1.2.3.4", ""); !allowed {
+		t.Fatal("expected the bucket to have refilled one token")
+	}
+}
+
+func TestLimiter_TokenBucketPerKeyPrefix(t *testing.T) {
+	cfg := testLimiterConfig()
+	cfg.BucketCapacity = 1
+	cfg.RefillInterval = time.Second
+	clock := newFakeClock(time.Unix(0, 0))
+	limiter := NewLimiter(cfg, nil, clock)
+
+	if allowed, _ := limiter.Allow("1.2.3.4", "badkey12"); !allowed {
+		t.Fatal("expected the first request to be allowed")
+	}
+	if allowed, _ := limiter.Allow("5.6.7.8", "badkey12"); allowed {
+		t.Error("expected a shared key prefix bucket to throttle a different IP")
+	}
+}
+
+func TestLimiter_SweepEvictsIdleBuckets(t *testing.T) {
+	clock := newFakeClock(time.Unix(0, 0))
+	limiter := NewLimiter(testLimiterConfig(), nil, clock)
+
+	limiter.RecordFailure("1.2.3.4", "")
+	clock.Advance(testLimiterConfig().Window + time.Second)
+
+	limiter.Sweep()
+
+	limiter.mu.Lock()
+	_, exists := limiter.byIP["1.2.3.4"]
+	limiter.mu.Unlock()
+
+	if exists {
+		t.Error("expected idle bucket to be evicted by Sweep")
+	}
+}
+
+func TestLimiter_SweepKeepsLockedOutBuckets(t *testing.T) {
+	clock := newFakeClock(time.Unix(0, 0))
+	limiter := NewLimiter(testLimiterConfig(), nil, clock)
+
+	for i := 0; i < 3; i++ {
+		limiter.RecordFailure("1.2.3.4", "")
+	}
+
+	limiter.Sweep()
+
+	limiter.mu.Lock()
+	_, exists := limiter.byIP["1.2.3.4"]
+	limiter.mu.Unlock()
+
+	if !exists {
+		t.Error("expected a still-locked-out bucket to survive Sweep")
+	}
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// Principal represents the identity associated with a successfully
+// authenticated API key.
+type Principal struct {
+	ID string
+}
+
+type contextKey int
+
+const principalContextKey contextKey = iota
+
+// FromContext returns the Principal stored in ctx by Middleware, and whether
+// one was present.
+func FromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalContextKey).(Principal)
+	return principal, ok
+}
+
+// Validator authenticates a raw API key (as returned by GetAPIKey) and
+// returns the Principal it belongs to. It implements KeyStore so a plain
+// function can be passed to Middleware directly, without a context.
+type Validator func(key string) (Principal, error)
+
+// Validate implements KeyStore by discarding ctx and calling v.
+func (v Validator) Validate(_ context.Context, key string) (Principal, error) {
+	return v(key)
+}
+
+// Middleware wraps an http.Handler with API key authentication. It extracts
+// the key from the request via GetAPIKey, passes it to store.Validate along
+// with the request's context, and on success stores the resulting Principal
+// in the request context for downstream handlers to read with FromContext.
+//
+// A missing or malformed key responds 401 Unauthorized; a key rejected by
+// store responds 403 Forbidden. Both responses carry a JSON body of the
+// form {"error": "..."}.
+func Middleware(store KeyStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, err := GetAPIKey(r.Header)
+			if err != nil {
+				respondAuthError(w, http.StatusUnauthorized, err.Error())
+				return
+			}
+
+			principal, err := store.Validate(r.Context(), key)
+			if err != nil {
+				respondAuthError(w, http.StatusForbidden, err.Error())
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), principalContextKey, principal)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func respondAuthError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func testValidator(key string) (Principal, error) {
+	if key == "good-key" {
+		return Principal{ID: "user-1"}, nil
+	}
+	return Principal{}, errors.New("invalid api key")
+}
+
+func testMiddlewareServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	handler := Middleware(Validator(testValidator))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := FromContext(r.Context())
+		if !ok {
+			t.Error("expected principal in request context")
+		}
+		w.Write([]byte(principal.ID))
+	}))
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestMiddleware_Success(t *testing.T) {
+	server := testMiddlewareServer(t)
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Authorization", "ApiKey good-key")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "user-1" {
+		t.Errorf("expected principal to be propagated via context, got body %q", body)
+	}
+}
+
+func TestMiddleware_Failures(t *testing.T) {
+	server := testMiddlewareServer(t)
+
+	tests := []struct {
+		name       string
+		authHeader string
+		wantStatus int
+	}{
+		{"MissingHeader", "", http.StatusUnauthorized},
+		{"MalformedHeader", "ApiKey", http.StatusUnauthorized},
+		{"UnsupportedScheme", "Basic dXNlcjpwYXNz", http.StatusUnauthorized},
+		{"InvalidKey", "ApiKey bad-key", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+
+			if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+				t.Errorf("expected JSON error body, got Content-Type %q", ct)
+			}
+
+			var payload struct {
+				Error string `json:"error"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+				t.Fatalf("failed to decode error body: %v", err)
+			}
+			if payload.Error == "" {
+				t.Error("expected non-empty error message")
+			}
+		})
+	}
+}